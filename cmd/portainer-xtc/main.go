@@ -0,0 +1,479 @@
+// Command portainer-xtc watches a directory of stack files and keeps
+// Portainer's stacks in sync with what's on disk.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/0x23me/portainer-xtc/pkg/gitsync"
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+	"github.com/0x23me/portainer-xtc/pkg/reconcile"
+	"github.com/0x23me/portainer-xtc/pkg/source"
+	"github.com/spf13/pflag"
+)
+
+const portainerHTTPTimeout = 120 * time.Second
+
+func main() {
+	if err := run(); err != nil {
+		panic(err)
+	}
+	os.Exit(0)
+}
+
+// Config holds all configuration for the application.
+type Config struct {
+	PortainerAddress string
+	APIKey           string
+	StackFilesDir    string
+	WatchStackdsDir  bool
+	MaxProcs         int
+	RetryLimit       int
+	NoSubstitute     bool
+	GitURL           string
+	GitRef           string
+	GitPollInterval  time.Duration
+	GitSSHKey        string
+	GitToken         string
+	Prune            bool
+	DryRun           bool
+	Output           string
+}
+
+// defaultConfig is a configuration with default values.
+var defaultConfig = Config{
+	StackFilesDir:   "./stacks/",
+	MaxProcs:        runtime.NumCPU(),
+	RetryLimit:      3,
+	GitRef:          "main",
+	GitPollInterval: 30 * time.Second,
+	Output:          "text",
+}
+
+func configFromFlags() Config {
+	cfg := defaultConfig
+
+	pflag.StringVar(&cfg.PortainerAddress, "portainer-address", cfg.PortainerAddress, "Address of Portainer api")
+	pflag.StringVar(&cfg.APIKey, "api-key", cfg.APIKey, "API Key")
+	pflag.StringVar(&cfg.StackFilesDir, "stack-files-dir", cfg.StackFilesDir, "Directory to stack files")
+	pflag.BoolVarP(&cfg.WatchStackdsDir, "watch-stacks-dir", "w", cfg.WatchStackdsDir, "Watch stacks dir")
+	pflag.IntVar(&cfg.MaxProcs, "max-procs", cfg.MaxProcs, "Maximum number of stacks to deploy concurrently")
+	pflag.IntVar(&cfg.RetryLimit, "retry-limit", cfg.RetryLimit, "Maximum number of additional attempts for a failed Portainer request")
+	pflag.BoolVar(&cfg.NoSubstitute, "no-substitute", cfg.NoSubstitute, "Disable ${VAR} environment substitution in stack files")
+	pflag.StringVar(&cfg.GitURL, "git-url", cfg.GitURL, "Git repository to sync stack-files-dir from, instead of watch-stacks-dir")
+	pflag.StringVar(&cfg.GitRef, "git-ref", cfg.GitRef, "Git branch to sync")
+	pflag.DurationVar(&cfg.GitPollInterval, "git-poll-interval", cfg.GitPollInterval, "How often to poll git-url for changes")
+	pflag.StringVar(&cfg.GitSSHKey, "git-ssh-key", cfg.GitSSHKey, "Path to an SSH private key for git-url")
+	pflag.StringVar(&cfg.GitToken, "git-token", cfg.GitToken, "Access token for git-url over HTTPS")
+	pflag.BoolVar(&cfg.Prune, "prune", cfg.Prune, "Delete Portainer stacks whose directory was removed from git-url")
+	pflag.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "Print what would change without creating, updating, or deleting any stack")
+	pflag.StringVar(&cfg.Output, "output", cfg.Output, "Output format for --dry-run: text or json")
+
+	pflag.Parse()
+
+	if !strings.HasSuffix(cfg.StackFilesDir, "/") {
+		cfg.StackFilesDir += "/"
+	}
+
+	return cfg
+}
+
+func run() error {
+	cfg := configFromFlags()
+
+	if cfg.PortainerAddress == "" {
+		return fmt.Errorf("portainer-address must be set")
+	}
+	if cfg.APIKey == "" {
+		return fmt.Errorf("api-key must be set")
+	}
+	if cfg.MaxProcs < 1 {
+		return fmt.Errorf("max-procs must be at least 1")
+	}
+	if cfg.Output != "text" && cfg.Output != "json" {
+		return fmt.Errorf("output must be 'text' or 'json'")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := portainer.NewClient(cfg.PortainerAddress, cfg.APIKey, portainerHTTPTimeout, cfg.RetryLimit)
+
+	endpoints, err := client.ListEndpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list endpoints: %w", err)
+	}
+	endpointsByName := make(map[string]portainer.Endpoint)
+	for _, e := range endpoints {
+		endpointsByName[e.Name] = e
+	}
+
+	stacks, err := client.ListStacks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list stacks: %w", err)
+	}
+	state := newLiveState(stacks)
+
+	r := reconcile.New(client)
+	loadOpts := source.Options{NoSubstitute: cfg.NoSubstitute}
+
+	if cfg.GitURL != "" {
+		return runGitSync(ctx, cfg, r, endpointsByName, state, loadOpts)
+	}
+
+	changes, err := source.Scan(cfg.StackFilesDir)
+	if err != nil {
+		return fmt.Errorf("failed to scan '%s': %w", cfg.StackFilesDir, err)
+	}
+
+	if cfg.DryRun {
+		return printPlan(ctx, r, changes, endpointsByName, state, loadOpts, cfg.Output)
+	}
+
+	deployAll(ctx, cfg.MaxProcs, r, changes, endpointsByName, state, loadOpts)
+
+	if cfg.WatchStackdsDir && ctx.Err() == nil {
+		w, err := source.Watch(cfg.StackFilesDir)
+		if err != nil {
+			return fmt.Errorf("failed to watch '%s': %w", cfg.StackFilesDir, err)
+		}
+		defer w.Stop()
+
+		log.Printf("Watching: %s", cfg.StackFilesDir)
+
+		changes := w.Changes()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("shutting down: %s", ctx.Err())
+				return nil
+			case change, ok := <-changes:
+				if !ok {
+					return nil
+				}
+				log.Printf("deploy: %s/%s", change.Endpoint, change.Stack)
+				deploy(ctx, r, change, endpointsByName, state, loadOpts)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runGitSync replaces watch-stacks-dir with a git-backed reconcile
+// loop: it clones/resets stack-files-dir from git-url on every
+// git-poll-interval tick and deploys only the stack directories that
+// changed.
+func runGitSync(ctx context.Context, cfg Config, r *reconcile.Reconciler, endpoints map[string]portainer.Endpoint, state *liveState, loadOpts source.Options) error {
+	syncer, err := gitsync.New(gitsync.Config{
+		URL:          cfg.GitURL,
+		Ref:          cfg.GitRef,
+		Dir:          cfg.StackFilesDir,
+		PollInterval: cfg.GitPollInterval,
+		SSHKeyPath:   cfg.GitSSHKey,
+		Token:        cfg.GitToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure git sync for '%s': %w", cfg.GitURL, err)
+	}
+
+	if _, err := syncer.Sync(ctx); err != nil {
+		return fmt.Errorf("failed to clone '%s': %w", cfg.GitURL, err)
+	}
+
+	changes, err := source.Scan(cfg.StackFilesDir)
+	if err != nil {
+		return fmt.Errorf("failed to scan '%s': %w", cfg.StackFilesDir, err)
+	}
+
+	if cfg.DryRun {
+		return printPlan(ctx, r, changes, endpoints, state, loadOpts, cfg.Output)
+	}
+
+	deployAll(ctx, cfg.MaxProcs, r, changes, endpoints, state, loadOpts)
+
+	log.Printf("git-sync: polling %s@%s every %s", cfg.GitURL, cfg.GitRef, cfg.GitPollInterval)
+
+	ticker := time.NewTicker(cfg.GitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("shutting down: %s", ctx.Err())
+			return nil
+		case <-ticker.C:
+			gitChanges, err := syncer.Sync(ctx)
+			if err != nil {
+				log.Printf("git-sync: failed to sync '%s': %s", cfg.GitURL, err)
+				continue
+			}
+
+			for _, c := range gitChanges {
+				if c.Deleted {
+					deleteStack(ctx, r, c, endpoints, state, cfg.Prune)
+					continue
+				}
+
+				change := source.StackChange{
+					Endpoint: c.Endpoint,
+					Stack:    c.Stack,
+					Path:     filepath.Join(cfg.StackFilesDir, c.Endpoint, c.Stack),
+				}
+				log.Printf("deploy: %s/%s", change.Endpoint, change.Stack)
+				deploy(ctx, r, change, endpoints, state, loadOpts)
+			}
+		}
+	}
+}
+
+func deleteStack(ctx context.Context, r *reconcile.Reconciler, change gitsync.Change, endpoints map[string]portainer.Endpoint, state *liveState, prune bool) {
+	if !prune {
+		log.Printf("skip delete %s/%s: --prune not set", change.Endpoint, change.Stack)
+		return
+	}
+
+	endpoint, ok := endpoints[change.Endpoint]
+	if !ok {
+		log.Printf("skip delete %s/%s: unknown endpoint", change.Endpoint, change.Stack)
+		return
+	}
+
+	log.Printf("delete stack %s on node %s", change.Stack, change.Endpoint)
+	if err := state.delete(ctx, r, endpoint, change.Stack); err != nil {
+		log.Printf("failed to delete stack: %s", err)
+	}
+}
+
+// liveState is the set of stacks Portainer currently knows about,
+// guarded by a mutex since it's read and written from every worker in
+// the pool.
+type liveState struct {
+	mu     sync.Mutex
+	stacks map[string]portainer.Stack
+}
+
+func newLiveState(stacks portainer.Stacks) *liveState {
+	s := &liveState{stacks: make(map[string]portainer.Stack, len(stacks))}
+	for _, st := range stacks {
+		s.stacks[st.Key()] = st
+	}
+	return s
+}
+
+func (s *liveState) decide(r *reconcile.Reconciler, endpoint portainer.Endpoint, stack string) (reconcile.Action, portainer.Stack) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return r.Decide(endpoint, stack, s.stacks)
+}
+
+func (s *liveState) recordCreate(stack portainer.Stack) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stacks[stack.Key()] = stack
+}
+
+// delete removes stack from Portainer (via r) and, on success, from
+// the live state map.
+func (s *liveState) delete(ctx context.Context, r *reconcile.Reconciler, endpoint portainer.Endpoint, stack string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := r.Delete(ctx, endpoint, stack, s.stacks); err != nil {
+		return err
+	}
+
+	delete(s.stacks, fmt.Sprintf("%d-%s", endpoint.ID, stack))
+	return nil
+}
+
+// deployAll runs a bounded pool of maxProcs workers that reconcile
+// changes concurrently, with live progress logging and a final summary.
+func deployAll(ctx context.Context, maxProcs int, r *reconcile.Reconciler, changes []source.StackChange, endpoints map[string]portainer.Endpoint, state *liveState, loadOpts source.Options) {
+	jobs := make(chan source.StackChange)
+	var done, count int64
+	count = int64(len(changes))
+
+	log.Printf("waiting for all stacks to be deployed")
+	statusDone := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-statusDone:
+				return
+			case <-time.After(5 * time.Second):
+				log.Printf("status: %d/%d", atomic.LoadInt64(&done), count)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxProcs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for change := range jobs {
+				deploy(ctx, r, change, endpoints, state, loadOpts)
+				atomic.AddInt64(&done, 1)
+			}
+		}()
+	}
+
+	now := time.Now()
+feed:
+	for _, change := range changes {
+		select {
+		case jobs <- change:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	close(statusDone)
+	log.Printf("all stacks deployed in: %s", time.Since(now))
+}
+
+// planResult is a single stack's entry in the --output json plan.
+type planResult struct {
+	Node   string `json:"node"`
+	Stack  string `json:"stack"`
+	Action string `json:"action"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// planSummary is the --output json document printed by printPlan.
+type planSummary struct {
+	Stacks      []planResult `json:"stacks"`
+	WouldCreate int          `json:"would_create"`
+	WouldUpdate int          `json:"would_update"`
+	Identical   int          `json:"identical"`
+}
+
+// printPlan resolves every change's desired content and reports what
+// Apply would do for it, without creating, updating, or deleting
+// anything in Portainer.
+func printPlan(ctx context.Context, r *reconcile.Reconciler, changes []source.StackChange, endpoints map[string]portainer.Endpoint, state *liveState, loadOpts source.Options, output string) error {
+	summary := planSummary{}
+
+	for _, change := range changes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		endpoint, ok := endpoints[change.Endpoint]
+		if !ok {
+			log.Printf("skip %s/%s: unknown endpoint", change.Endpoint, change.Stack)
+			continue
+		}
+
+		stack, err := source.Load(change, loadOpts)
+		if err != nil {
+			log.Printf("failed to load stack '%s/%s': %s", change.Endpoint, change.Stack, err)
+			continue
+		}
+
+		desired := reconcile.Desired{
+			Node:      change.Endpoint,
+			Stack:     change.Stack,
+			Type:      stack.Type,
+			Content:   stack.Content,
+			Namespace: stack.Namespace,
+			Env:       stack.Env,
+			PullImage: stack.PullImage,
+			Prune:     stack.Prune,
+		}
+
+		action, liveStack := state.decide(r, endpoint, change.Stack)
+		p, err := r.Plan(ctx, endpoint, desired, action, liveStack)
+		if err != nil {
+			log.Printf("failed to plan stack '%s/%s': %s", change.Endpoint, change.Stack, err)
+			continue
+		}
+
+		switch {
+		case p.Identical:
+			summary.Identical++
+		case action == reconcile.ActionCreate:
+			summary.WouldCreate++
+		default:
+			summary.WouldUpdate++
+		}
+
+		if output == "json" {
+			summary.Stacks = append(summary.Stacks, planResult{
+				Node:   change.Endpoint,
+				Stack:  change.Stack,
+				Action: action.String(),
+				Diff:   p.Diff,
+			})
+			continue
+		}
+
+		if p.Diff != "" {
+			fmt.Printf("--- %s/%s (%s)\n%s\n", change.Endpoint, change.Stack, action, p.Diff)
+		}
+	}
+
+	if output == "json" {
+		return json.NewEncoder(os.Stdout).Encode(summary)
+	}
+
+	fmt.Printf("would create: %d\nwould update: %d\nidentical: %d\n", summary.WouldCreate, summary.WouldUpdate, summary.Identical)
+	return nil
+}
+
+func deploy(ctx context.Context, r *reconcile.Reconciler, change source.StackChange, endpoints map[string]portainer.Endpoint, state *liveState, loadOpts source.Options) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	endpoint, ok := endpoints[change.Endpoint]
+	if !ok {
+		log.Printf("skip %s/%s: unknown endpoint", change.Endpoint, change.Stack)
+		return
+	}
+
+	stack, err := source.Load(change, loadOpts)
+	if err != nil {
+		log.Printf("failed to load stack '%s/%s': %s", change.Endpoint, change.Stack, err)
+		return
+	}
+
+	desired := reconcile.Desired{
+		Node:      change.Endpoint,
+		Stack:     change.Stack,
+		Type:      stack.Type,
+		Content:   stack.Content,
+		Namespace: stack.Namespace,
+		Env:       stack.Env,
+		PullImage: stack.PullImage,
+		Prune:     stack.Prune,
+	}
+
+	action, liveStack := state.decide(r, endpoint, change.Stack)
+	log.Printf("%s stack %s on node %s", action, change.Stack, change.Endpoint)
+
+	applied, err := r.Apply(ctx, endpoint, desired, action, liveStack)
+	if err != nil {
+		log.Printf("failed to %s stack: %s", action, err)
+		return
+	}
+
+	if action == reconcile.ActionCreate {
+		state.recordCreate(applied)
+	}
+}
@@ -0,0 +1,298 @@
+// Package gitsync keeps a directory in sync with a remote git
+// repository, polling for changes and reporting which stack
+// directories they touched.
+package gitsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x23me/portainer-xtc/pkg/source"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+const remoteName = "origin"
+
+// Config configures a Syncer.
+type Config struct {
+	URL          string
+	Ref          string
+	Dir          string
+	PollInterval time.Duration
+	SSHKeyPath   string
+	Token        string
+}
+
+// Change is a single <node>/<stack> directory that differs between two
+// synced revisions.
+type Change struct {
+	Endpoint string
+	Stack    string
+	Deleted  bool
+}
+
+// Syncer clones cfg.URL into cfg.Dir on first Sync (or opens the
+// repository already there), then fetches and
+// hard-resets to cfg.Ref on every subsequent Sync, reporting which
+// stack directories changed in between.
+type Syncer struct {
+	cfg  Config
+	auth transport.AuthMethod
+	repo *git.Repository
+	head plumbing.Hash
+}
+
+// New returns a Syncer for cfg. Apart from reading cfg.SSHKeyPath when
+// it's set, it does not touch the network or disk until Sync is called.
+func New(cfg Config) (*Syncer, error) {
+	auth, err := authMethod(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{cfg: cfg, auth: auth}, nil
+}
+
+func authMethod(cfg Config) (transport.AuthMethod, error) {
+	switch {
+	case cfg.SSHKeyPath != "":
+		auth, err := ssh.NewPublicKeysFromFile("git", cfg.SSHKeyPath, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ssh key '%s': %w", cfg.SSHKeyPath, err)
+		}
+		return auth, nil
+	case cfg.Token != "":
+		return &githttp.BasicAuth{Username: "x-access-token", Password: cfg.Token}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Sync brings cfg.Dir up to date with cfg.Ref and returns the stack
+// directories that changed. The first call only establishes the
+// baseline revision and reports no changes, since there's nothing to
+// diff against yet.
+func (s *Syncer) Sync(ctx context.Context) ([]Change, error) {
+	if s.repo == nil {
+		return s.init(ctx)
+	}
+
+	return s.fetchAndReset(ctx)
+}
+
+func (s *Syncer) init(ctx context.Context) ([]Change, error) {
+	repo, err := git.PlainOpen(s.cfg.Dir)
+	switch err {
+	case nil:
+		s.repo = repo
+	case git.ErrRepositoryNotExists:
+		repo, err = git.PlainCloneContext(ctx, s.cfg.Dir, false, &git.CloneOptions{
+			URL:           s.cfg.URL,
+			Auth:          s.auth,
+			ReferenceName: plumbing.NewBranchReferenceName(s.cfg.Ref),
+			SingleBranch:  true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone '%s': %w", s.cfg.URL, err)
+		}
+		s.repo = repo
+	default:
+		return nil, fmt.Errorf("failed to open '%s': %w", s.cfg.Dir, err)
+	}
+
+	head, err := s.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	s.head = head.Hash()
+
+	return nil, nil
+}
+
+func (s *Syncer) fetchAndReset(ctx context.Context) ([]Change, error) {
+	refSpec := config.RefSpec(fmt.Sprintf("+refs/heads/%s:refs/remotes/%s/%s", s.cfg.Ref, remoteName, s.cfg.Ref))
+	err := s.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		Auth:       s.auth,
+		RefSpecs:   []config.RefSpec{refSpec},
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("failed to fetch '%s': %w", s.cfg.URL, err)
+	}
+
+	remoteRef, err := s.repo.Reference(plumbing.NewRemoteReferenceName(remoteName, s.cfg.Ref), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve '%s/%s': %w", remoteName, s.cfg.Ref, err)
+	}
+
+	if remoteRef.Hash() == s.head {
+		return nil, nil
+	}
+
+	seen, envScopes, err := s.diff(s.head, remoteRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: remoteRef.Hash(), Mode: git.HardReset}); err != nil {
+		return nil, fmt.Errorf("failed to reset to %s: %w", remoteRef.Hash(), err)
+	}
+
+	s.head = remoteRef.Hash()
+
+	// Scoped .env changes are expanded against the working tree, so
+	// this has to happen after the reset above puts it at remoteRef.
+	if len(envScopes) > 0 {
+		if err := s.expandEnvScopes(envScopes, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make([]Change, 0, len(seen))
+	for _, c := range seen {
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// diff reports, for every path that changed between from and to, which
+// <node>/<stack> directory it belongs to and whether that directory no
+// longer exists at to, plus the set of shared .env scopes touched. A stack
+// touched by more than one file collapses to a single Change. A change
+// to a shared stacks/.env or stacks/<node>/.env overlay isn't itself a
+// stack directory, so it's reported separately and expanded by the
+// caller once the working tree reflects the new revision.
+func (s *Syncer) diff(from, to plumbing.Hash) (map[string]Change, map[string]bool, error) {
+	fromCommit, err := s.repo.CommitObject(from)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load commit %s: %w", from, err)
+	}
+	toCommit, err := s.repo.CommitObject(to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load commit %s: %w", to, err)
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load tree for %s: %w", from, err)
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load tree for %s: %w", to, err)
+	}
+
+	treeChanges, err := fromTree.Diff(toTree)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to diff %s..%s: %w", from, to, err)
+	}
+
+	seen := make(map[string]Change)
+	envScopes := make(map[string]bool)
+	for _, tc := range treeChanges {
+		action, err := tc.Action()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read diff action: %w", err)
+		}
+
+		path := tc.To.Name
+		deleted := action == merkletrie.Delete
+		if deleted {
+			path = tc.From.Name
+		}
+
+		if scope, ok := envScope(path); ok {
+			envScopes[scope] = true
+			continue
+		}
+
+		endpoint, stack, ok := splitStackPath(path)
+		if !ok {
+			continue
+		}
+
+		if deleted {
+			// Removing one file from a stack directory that's still
+			// present at to doesn't remove the stack.
+			if _, err := toTree.Tree(endpoint + "/" + stack); err == nil {
+				deleted = false
+			}
+		}
+
+		key := endpoint + "/" + stack
+		if existing, ok := seen[key]; ok && !existing.Deleted {
+			// An earlier non-delete change to the same stack wins, since
+			// it means the stack still exists at the target revision.
+			deleted = false
+		}
+
+		seen[key] = Change{Endpoint: endpoint, Stack: stack, Deleted: deleted}
+	}
+
+	return seen, envScopes, nil
+}
+
+func splitStackPath(path string) (endpoint, stack string, ok bool) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) < 3 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// envScope reports whether path is a shared .env overlay: "" (and ok)
+// for the root stacks/.env, which is in scope for every stack, or a
+// node name for stacks/<node>/.env, which is in scope for every stack
+// under that node.
+func envScope(path string) (scope string, ok bool) {
+	parts := strings.Split(path, "/")
+	switch {
+	case len(parts) == 1 && parts[0] == ".env":
+		return "", true
+	case len(parts) == 2 && parts[1] == ".env":
+		return parts[0], true
+	default:
+		return "", false
+	}
+}
+
+// expandEnvScopes adds a Change for every stack directory in scopes,
+// since an edit to a shared .env overlay changes every stack that
+// loads it even though the overlay itself isn't a stack directory. A
+// stack already recorded as deleted is left alone: there's nothing to
+// redeploy there.
+func (s *Syncer) expandEnvScopes(scopes map[string]bool, seen map[string]Change) error {
+	changes, err := source.Scan(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan '%s' for env-scoped stacks: %w", s.cfg.Dir, err)
+	}
+
+	_, all := scopes[""]
+	for _, c := range changes {
+		if !all && !scopes[c.Endpoint] {
+			continue
+		}
+
+		key := c.Endpoint + "/" + c.Stack
+		if existing, ok := seen[key]; ok && existing.Deleted {
+			continue
+		}
+
+		seen[key] = Change{Endpoint: c.Endpoint, Stack: c.Stack}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package gitsync
+
+import "testing"
+
+func TestEnvScope(t *testing.T) {
+	tests := []struct {
+		path      string
+		wantScope string
+		wantOK    bool
+	}{
+		{path: ".env", wantScope: "", wantOK: true},
+		{path: "nodeA/.env", wantScope: "nodeA", wantOK: true},
+		{path: "nodeA/stackA/.env", wantOK: false},
+		{path: "nodeA/stackA/docker-compose.yml", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := envScope(tt.path)
+		if ok != tt.wantOK {
+			t.Errorf("envScope(%q) ok = %v, want %v", tt.path, ok, tt.wantOK)
+			continue
+		}
+		if ok && scope != tt.wantScope {
+			t.Errorf("envScope(%q) scope = %q, want %q", tt.path, scope, tt.wantScope)
+		}
+	}
+}
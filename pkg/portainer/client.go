@@ -0,0 +1,213 @@
+// Package portainer is a small typed client for the handful of Portainer
+// API endpoints portainer-xtc needs: endpoints, stacks, and per-stack
+// file contents.
+package portainer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	pclient "github.com/portainer/portainer/api/http/client"
+)
+
+// Client talks to a single Portainer instance.
+type Client struct {
+	http       *pclient.HTTPClient
+	address    string
+	apiKey     string
+	retryLimit int
+}
+
+// NewClient returns a Client that talks to the Portainer instance at
+// address, authenticating with apiKey. retryLimit is the maximum number
+// of additional attempts doRequest makes for a transient failure.
+func NewClient(address, apiKey string, timeout time.Duration, retryLimit int) *Client {
+	h := pclient.NewHTTPClient()
+	h.Timeout = timeout
+
+	return &Client{
+		http:       h,
+		address:    address,
+		apiKey:     apiKey,
+		retryLimit: retryLimit,
+	}
+}
+
+func (c *Client) endpointsURL() string {
+	return fmt.Sprintf("%s/api/endpoints", c.address)
+}
+
+func (c *Client) stacksURL() string {
+	return fmt.Sprintf("%s/api/stacks", c.address)
+}
+
+func (c *Client) stackFileURL(stackID int) string {
+	return fmt.Sprintf("%s/api/stacks/%d/file", c.address, stackID)
+}
+
+func (c *Client) stackURL(stackID, endpointID int) string {
+	return fmt.Sprintf("%s/api/stacks/%d?endpointId=%d", c.address, stackID, endpointID)
+}
+
+// ListEndpoints returns every endpoint known to Portainer.
+func (c *Client) ListEndpoints(ctx context.Context) (Endpoints, error) {
+	var endpoints Endpoints
+	if err := c.doRequest(ctx, http.MethodGet, c.endpointsURL(), nil, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to list endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+// ListStacks returns every stack known to Portainer.
+func (c *Client) ListStacks(ctx context.Context) (Stacks, error) {
+	var stacks Stacks
+	if err := c.doRequest(ctx, http.MethodGet, c.stacksURL(), nil, &stacks); err != nil {
+		return nil, fmt.Errorf("failed to list stacks: %w", err)
+	}
+
+	return stacks, nil
+}
+
+// GetStackFile fetches the compose file Portainer currently has on
+// record for stackID.
+func (c *Client) GetStackFile(ctx context.Context, stackID int) (string, error) {
+	var resp StackFileResponse
+	if err := c.doRequest(ctx, http.MethodGet, c.stackFileURL(stackID), nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to fetch stack file: %w", err)
+	}
+
+	return resp.StackFileContent, nil
+}
+
+// CreateStack creates a new stack of stackType on endpointID and returns
+// the stack Portainer created, including its assigned ID.
+func (c *Client) CreateStack(ctx context.Context, stackType StackType, reqBody CreateStackRequest, endpointID int) (Stack, error) {
+	b, err := json.Marshal(&reqBody)
+	if err != nil {
+		return Stack{}, fmt.Errorf("failed to marshal CreateStackRequest: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/stacks/create/%s/string?endpointId=%d", c.address, createMethodSegment(stackType), endpointID)
+
+	var created Stack
+	if err := c.doRequest(ctx, http.MethodPost, url, b, &created); err != nil {
+		return Stack{}, fmt.Errorf("failed to create stack: %w", err)
+	}
+
+	return created, nil
+}
+
+// SwarmID returns the Swarm cluster ID for endpointID, needed to create
+// a StackTypeSwarm stack there.
+func (c *Client) SwarmID(ctx context.Context, endpointID int) (string, error) {
+	url := fmt.Sprintf("%s/api/endpoints/%d/docker/swarm", c.address, endpointID)
+
+	var info SwarmInfo
+	if err := c.doRequest(ctx, http.MethodGet, url, nil, &info); err != nil {
+		return "", fmt.Errorf("failed to fetch swarm id: %w", err)
+	}
+
+	return info.ID, nil
+}
+
+func createMethodSegment(stackType StackType) string {
+	switch stackType {
+	case StackTypeSwarm:
+		return "swarm"
+	case StackTypeKubernetes:
+		return "kubernetes"
+	default:
+		return "standalone"
+	}
+}
+
+// UpdateStack pushes a new compose file for an existing stack.
+func (c *Client) UpdateStack(ctx context.Context, stackID, endpointID int, body BodyComposeUpdate) error {
+	b, err := json.Marshal(&body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal BodyComposeUpdate: %w", err)
+	}
+
+	if err := c.doRequest(ctx, http.MethodPut, c.stackURL(stackID, endpointID), b, nil); err != nil {
+		return fmt.Errorf("failed to update stack: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteStack removes a stack from Portainer.
+func (c *Client) DeleteStack(ctx context.Context, stackID, endpointID int) error {
+	if err := c.doRequest(ctx, http.MethodDelete, c.stackURL(stackID, endpointID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete stack: %w", err)
+	}
+
+	return nil
+}
+
+// doRequest issues method/url with body (nil for no body), decoding the
+// response into res (nil to discard it) only on a 2xx/3xx status. Any
+// 4xx/5xx status is an error, distinct from whether it's worth
+// retrying. It retries transient failures with exponential backoff, up
+// to c.retryLimit additional attempts, and aborts early if ctx is
+// cancelled.
+func (c *Client) doRequest(ctx context.Context, method, url string, body []byte, res interface{}) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= c.retryLimit; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff(attempt)):
+			}
+		}
+
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+		if err != nil {
+			return fmt.Errorf("failed to create %s request for %s: %w", method, url, err)
+		}
+		req.Header.Add("X-API-Key", c.apiKey)
+
+		resp, err := c.http.Do(req)
+		if err == nil && resp.StatusCode < 400 {
+			defer resp.Body.Close()
+			if res != nil {
+				if derr := json.NewDecoder(resp.Body).Decode(res); derr != nil {
+					return fmt.Errorf("could not decode response: %w", derr)
+				}
+			}
+			return nil
+		}
+
+		if err != nil {
+			lastErr = fmt.Errorf("failed to make request: %w", err)
+		} else {
+			lastErr = fmt.Errorf("request to %s failed with status %d", url, resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		if !shouldRetry(statusOrZero(resp), err) {
+			return lastErr
+		}
+	}
+
+	return lastErr
+}
+
+func statusOrZero(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,40 @@
+package portainer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoRequestReturnsErrorForNonRetryableStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"stack not found"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "api-key", time.Second, 0)
+
+	if _, err := c.GetStackFile(context.Background(), 42); err == nil {
+		t.Fatal("expected an error for a 404 response")
+	}
+}
+
+func TestDoRequestDecodesSuccessResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"StackFileContent":"version: '3'\n"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "api-key", time.Second, 0)
+
+	content, err := c.GetStackFile(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetStackFile returned error: %s", err)
+	}
+	if content != "version: '3'\n" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+}
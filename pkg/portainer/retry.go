@@ -0,0 +1,36 @@
+package portainer
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxBackoff caps the exponential backoff between retries.
+const maxBackoff = 30 * time.Second
+
+// shouldRetry reports whether a request that got statusCode (or failed
+// outright with err) is worth retrying: any connection error, or a 502,
+// 503 or 504 status. The same rule applies to every method, so POST and
+// PUT are retried on these failures just as GET is.
+func shouldRetry(statusCode int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before retry attempt n (n starts at 1 for
+// the first retry): 1s, 2s, 4s, ..., capped at maxBackoff.
+func backoff(n int) time.Duration {
+	d := time.Second << uint(n-1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,76 @@
+package portainer
+
+import "fmt"
+
+// Endpoint is a Portainer environment (what the UI calls an "endpoint").
+type Endpoint struct {
+	ID   int    `json:"Id"`
+	Name string `json:"Name"`
+}
+
+// Endpoints is a list of Endpoint, as returned by GET /api/endpoints.
+type Endpoints []Endpoint
+
+// Stack is a deployed Portainer stack.
+type Stack struct {
+	ID         int    `json:"Id"`
+	Name       string `json:"Name"`
+	Status     int    `json:"Status"`
+	EndpointID int    `json:"EndpointId"`
+}
+
+// Stacks is a list of Stack, as returned by GET /api/stacks.
+type Stacks []Stack
+
+// Key uniquely identifies a stack within an endpoint, matching how
+// Portainer scopes stack names per endpoint.
+func (s Stack) Key() string {
+	return fmt.Sprintf("%d-%s", s.EndpointID, s.Name)
+}
+
+// StackType is the kind of stack Portainer deploys: standalone compose,
+// Swarm, or Kubernetes. It matches Portainer's own type enum.
+type StackType int
+
+const (
+	StackTypeSwarm      StackType = 1
+	StackTypeCompose    StackType = 2
+	StackTypeKubernetes StackType = 3
+)
+
+// EnvVar is a single stack environment variable, as Portainer's create
+// and update endpoints expect them.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CreateStackRequest is the POST body for /api/stacks/create/{type}/string.
+// SwarmID is required for StackTypeSwarm; Namespace is required for
+// StackTypeKubernetes.
+type CreateStackRequest struct {
+	Name             string   `json:"Name"`
+	StackFileContent string   `json:"StackFileContent"`
+	SwarmID          string   `json:"SwarmID,omitempty"`
+	Namespace        string   `json:"Namespace,omitempty"`
+	Env              []EnvVar `json:"Env,omitempty"`
+}
+
+// BodyComposeUpdate is the PUT body for /api/stacks/{id}. Prune is only
+// meaningful for Swarm and Kubernetes stacks.
+type BodyComposeUpdate struct {
+	StackFileContent string   `json:"stackFileContent"`
+	PullImage        bool     `json:"pullImage"`
+	Prune            bool     `json:"prune,omitempty"`
+	Env              []EnvVar `json:"env,omitempty"`
+}
+
+// SwarmInfo is the body of GET /api/endpoints/{id}/docker/swarm.
+type SwarmInfo struct {
+	ID string `json:"ID"`
+}
+
+// StackFileResponse is the body of GET /api/stacks/{id}/file.
+type StackFileResponse struct {
+	StackFileContent string `json:"StackFileContent"`
+}
@@ -0,0 +1,65 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// PlanResult is what Apply would do for a stack, without doing it.
+type PlanResult struct {
+	Action Action
+	// Diff is a unified diff from what Portainer currently has to
+	// desired.Content. For ActionCreate it diffs from an empty file,
+	// since Portainer has nothing yet; it's empty for an ActionUpdate
+	// that's Identical.
+	Diff string
+	// Identical is true when an ActionUpdate's desired content already
+	// matches what Portainer has.
+	Identical bool
+}
+
+// Plan reports what Apply would do for action without applying it: for
+// ActionUpdate it fetches the stack's current file from Portainer and
+// diffs it against desired.Content, same as Apply, but stops short of
+// calling UpdateStack.
+func (r *Reconciler) Plan(ctx context.Context, endpoint portainer.Endpoint, desired Desired, action Action, live portainer.Stack) (PlanResult, error) {
+	switch action {
+	case ActionUpdate:
+		current, err := r.Client.GetStackFile(ctx, live.ID)
+		if err != nil {
+			return PlanResult{}, fmt.Errorf("failed to fetch current stack file for %s: %w", desired.Stack, err)
+		}
+
+		if current == desired.Content {
+			return PlanResult{Action: action, Identical: true}, nil
+		}
+
+		return PlanResult{Action: action, Diff: unifiedDiff(desired.Stack, current, desired.Content)}, nil
+
+	case ActionCreate:
+		return PlanResult{Action: action, Diff: unifiedDiff(desired.Stack, "", desired.Content)}, nil
+
+	default:
+		return PlanResult{Action: action, Identical: true}, nil
+	}
+}
+
+func unifiedDiff(name, from, to string) string {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "portainer/" + name,
+		ToFile:   "local/" + name,
+		Context:  3,
+	}
+
+	text, err := difflib.GetUnifiedDiffString(diff)
+	if err != nil {
+		return fmt.Sprintf("failed to render diff for %s: %s", name, err)
+	}
+
+	return text
+}
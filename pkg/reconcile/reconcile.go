@@ -0,0 +1,154 @@
+// Package reconcile decides whether a stack found on disk needs to be
+// created, updated, or left alone, and applies that decision through a
+// Client.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+)
+
+// Action is the outcome of a reconcile Decide call.
+type Action int
+
+const (
+	// ActionSkip means the stack on disk already matches Portainer.
+	ActionSkip Action = iota
+	// ActionCreate means no stack exists on the endpoint yet.
+	ActionCreate
+	// ActionUpdate means a stack exists and may need a new file pushed.
+	ActionUpdate
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	default:
+		return "skip"
+	}
+}
+
+// Client is the subset of the Portainer API the reconciler needs.
+// Defining it here, rather than depending on *portainer.Client directly,
+// lets tests exercise a Reconciler against a fake.
+type Client interface {
+	GetStackFile(ctx context.Context, stackID int) (string, error)
+	CreateStack(ctx context.Context, stackType portainer.StackType, req portainer.CreateStackRequest, endpointID int) (portainer.Stack, error)
+	UpdateStack(ctx context.Context, stackID, endpointID int, body portainer.BodyComposeUpdate) error
+	DeleteStack(ctx context.Context, stackID, endpointID int) error
+	SwarmID(ctx context.Context, endpointID int) (string, error)
+}
+
+// Desired is the state we want a stack to be in, as read from disk.
+type Desired struct {
+	Node      string
+	Stack     string
+	Type      portainer.StackType
+	Content   string
+	Namespace string
+	Env       []portainer.EnvVar
+	PullImage bool
+	Prune     bool
+}
+
+// Reconciler decides and applies create/update actions for stacks.
+type Reconciler struct {
+	Client Client
+}
+
+// New returns a Reconciler that applies changes through c.
+func New(c Client) *Reconciler {
+	return &Reconciler{Client: c}
+}
+
+// Decide looks up stack in the live state map and reports whether it
+// needs to be created or updated. It does not talk to Portainer.
+func (r *Reconciler) Decide(endpoint portainer.Endpoint, stack string, live map[string]portainer.Stack) (Action, portainer.Stack) {
+	key := fmt.Sprintf("%d-%s", endpoint.ID, stack)
+
+	s, ok := live[key]
+	if !ok {
+		return ActionCreate, portainer.Stack{}
+	}
+
+	return ActionUpdate, s
+}
+
+// Apply performs the create or update decided by Decide. For updates,
+// it fetches the stack's current file from Portainer and skips the PUT
+// if it already matches desired.Content. ctx cancellation aborts any
+// in-flight request. On ActionCreate, the returned Stack is what
+// Portainer assigned (including its real ID); callers should use it to
+// update their live state instead of assuming a zero value.
+func (r *Reconciler) Apply(ctx context.Context, endpoint portainer.Endpoint, desired Desired, action Action, live portainer.Stack) (portainer.Stack, error) {
+	switch action {
+	case ActionCreate:
+		if desired.Type == portainer.StackTypeKubernetes && desired.Namespace == "" {
+			return portainer.Stack{}, fmt.Errorf("stack %s/%s is a kubernetes stack but has no namespace set (add a namespace to its manifest.yaml)", desired.Node, desired.Stack)
+		}
+
+		req := portainer.CreateStackRequest{
+			Name:             desired.Stack,
+			StackFileContent: desired.Content,
+			Namespace:        desired.Namespace,
+			Env:              desired.Env,
+		}
+
+		if desired.Type == portainer.StackTypeSwarm {
+			swarmID, err := r.Client.SwarmID(ctx, endpoint.ID)
+			if err != nil {
+				return portainer.Stack{}, fmt.Errorf("failed to resolve swarm id for endpoint %s: %w", endpoint.Name, err)
+			}
+			req.SwarmID = swarmID
+		}
+
+		created, err := r.Client.CreateStack(ctx, desired.Type, req, endpoint.ID)
+		if err != nil {
+			return portainer.Stack{}, err
+		}
+
+		return created, nil
+
+	case ActionUpdate:
+		plan, err := r.Plan(ctx, endpoint, desired, action, live)
+		if err != nil {
+			return portainer.Stack{}, err
+		}
+
+		if plan.Identical {
+			return live, nil
+		}
+
+		if err := r.Client.UpdateStack(ctx, live.ID, endpoint.ID, portainer.BodyComposeUpdate{
+			StackFileContent: desired.Content,
+			PullImage:        desired.PullImage,
+			Prune:            desired.Prune,
+			Env:              desired.Env,
+		}); err != nil {
+			return portainer.Stack{}, err
+		}
+
+		return live, nil
+
+	default:
+		return live, nil
+	}
+}
+
+// Delete removes stack from Portainer if it's present in the live state
+// map; it's a no-op otherwise, since there's nothing to prune.
+func (r *Reconciler) Delete(ctx context.Context, endpoint portainer.Endpoint, stack string, live map[string]portainer.Stack) error {
+	key := fmt.Sprintf("%d-%s", endpoint.ID, stack)
+
+	s, ok := live[key]
+	if !ok {
+		return nil
+	}
+
+	return r.Client.DeleteStack(ctx, s.ID, endpoint.ID)
+}
@@ -0,0 +1,230 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+)
+
+type fakeClient struct {
+	files   map[int]string
+	created []portainer.CreateStackRequest
+	updated []portainer.BodyComposeUpdate
+	deleted []int
+}
+
+func (f *fakeClient) GetStackFile(ctx context.Context, stackID int) (string, error) {
+	return f.files[stackID], nil
+}
+
+func (f *fakeClient) CreateStack(ctx context.Context, stackType portainer.StackType, req portainer.CreateStackRequest, endpointID int) (portainer.Stack, error) {
+	f.created = append(f.created, req)
+	return portainer.Stack{ID: 99, Name: req.Name, EndpointID: endpointID}, nil
+}
+
+func (f *fakeClient) SwarmID(ctx context.Context, endpointID int) (string, error) {
+	return "swarm-1", nil
+}
+
+func (f *fakeClient) UpdateStack(ctx context.Context, stackID, endpointID int, body portainer.BodyComposeUpdate) error {
+	f.updated = append(f.updated, body)
+	return nil
+}
+
+func (f *fakeClient) DeleteStack(ctx context.Context, stackID, endpointID int) error {
+	f.deleted = append(f.deleted, stackID)
+	return nil
+}
+
+func TestDecideCreate(t *testing.T) {
+	r := New(&fakeClient{})
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+
+	action, _ := r.Decide(endpoint, "my-stack", map[string]portainer.Stack{})
+	if action != ActionCreate {
+		t.Fatalf("expected ActionCreate, got %s", action)
+	}
+}
+
+func TestDecideUpdate(t *testing.T) {
+	r := New(&fakeClient{})
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	live := map[string]portainer.Stack{
+		"1-my-stack": {ID: 42, Name: "my-stack", EndpointID: 1},
+	}
+
+	action, s := r.Decide(endpoint, "my-stack", live)
+	if action != ActionUpdate {
+		t.Fatalf("expected ActionUpdate, got %s", action)
+	}
+	if s.ID != 42 {
+		t.Fatalf("expected stack ID 42, got %d", s.ID)
+	}
+}
+
+func TestApplyUpdateSkipsIdenticalContent(t *testing.T) {
+	fc := &fakeClient{files: map[int]string{42: "version: '3'\n"}}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3'\n"}
+
+	if _, err := r.Apply(context.Background(), endpoint, desired, ActionUpdate, portainer.Stack{ID: 42}); err != nil {
+		t.Fatalf("Apply returned error: %s", err)
+	}
+	if len(fc.updated) != 0 {
+		t.Fatalf("expected no update for identical content, got %d", len(fc.updated))
+	}
+}
+
+func TestApplyUpdatePushesChangedContent(t *testing.T) {
+	fc := &fakeClient{files: map[int]string{42: "version: '3'\n"}}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3.8'\n"}
+
+	if _, err := r.Apply(context.Background(), endpoint, desired, ActionUpdate, portainer.Stack{ID: 42}); err != nil {
+		t.Fatalf("Apply returned error: %s", err)
+	}
+	if len(fc.updated) != 1 {
+		t.Fatalf("expected one update, got %d", len(fc.updated))
+	}
+	if fc.updated[0].StackFileContent != desired.Content {
+		t.Fatalf("unexpected update content: %q", fc.updated[0].StackFileContent)
+	}
+}
+
+func TestApplyCreate(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3'\n"}
+
+	created, err := r.Apply(context.Background(), endpoint, desired, ActionCreate, portainer.Stack{})
+	if err != nil {
+		t.Fatalf("Apply returned error: %s", err)
+	}
+	if len(fc.created) != 1 {
+		t.Fatalf("expected one create, got %d", len(fc.created))
+	}
+	if fc.created[0].Name != "my-stack" {
+		t.Fatalf("unexpected create name: %q", fc.created[0].Name)
+	}
+	if created.ID != 99 {
+		t.Fatalf("expected Apply to return the created stack's real ID, got %d", created.ID)
+	}
+}
+
+func TestApplyCreateSwarmResolvesSwarmID(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Type: portainer.StackTypeSwarm, Content: "version: '3'\n"}
+
+	if _, err := r.Apply(context.Background(), endpoint, desired, ActionCreate, portainer.Stack{}); err != nil {
+		t.Fatalf("Apply returned error: %s", err)
+	}
+	if len(fc.created) != 1 {
+		t.Fatalf("expected one create, got %d", len(fc.created))
+	}
+	if fc.created[0].SwarmID != "swarm-1" {
+		t.Fatalf("expected resolved swarm id, got %q", fc.created[0].SwarmID)
+	}
+}
+
+func TestApplyCreateKubernetesRequiresNamespace(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Type: portainer.StackTypeKubernetes, Content: "kind: Deployment\n"}
+
+	if _, err := r.Apply(context.Background(), endpoint, desired, ActionCreate, portainer.Stack{}); err == nil {
+		t.Fatal("expected an error for a kubernetes stack with no namespace")
+	}
+	if len(fc.created) != 0 {
+		t.Fatalf("expected no create call, got %d", len(fc.created))
+	}
+}
+
+func TestDeleteRemovesKnownStack(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	live := map[string]portainer.Stack{
+		"1-my-stack": {ID: 42, Name: "my-stack", EndpointID: 1},
+	}
+
+	if err := r.Delete(context.Background(), endpoint, "my-stack", live); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if len(fc.deleted) != 1 || fc.deleted[0] != 42 {
+		t.Fatalf("expected delete of stack 42, got %v", fc.deleted)
+	}
+}
+
+func TestPlanUpdateIdenticalContent(t *testing.T) {
+	fc := &fakeClient{files: map[int]string{42: "version: '3'\n"}}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3'\n"}
+
+	plan, err := r.Plan(context.Background(), endpoint, desired, ActionUpdate, portainer.Stack{ID: 42})
+	if err != nil {
+		t.Fatalf("Plan returned error: %s", err)
+	}
+	if !plan.Identical {
+		t.Fatalf("expected identical plan, got %+v", plan)
+	}
+	if len(fc.updated) != 0 {
+		t.Fatalf("expected Plan not to update, got %d", len(fc.updated))
+	}
+}
+
+func TestPlanUpdateChangedContent(t *testing.T) {
+	fc := &fakeClient{files: map[int]string{42: "version: '3'\n"}}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3.8'\n"}
+
+	plan, err := r.Plan(context.Background(), endpoint, desired, ActionUpdate, portainer.Stack{ID: 42})
+	if err != nil {
+		t.Fatalf("Plan returned error: %s", err)
+	}
+	if plan.Identical {
+		t.Fatal("expected non-identical plan")
+	}
+	if plan.Diff == "" {
+		t.Fatal("expected a non-empty diff")
+	}
+}
+
+func TestPlanCreate(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+	desired := Desired{Node: "node-a", Stack: "my-stack", Content: "version: '3'\n"}
+
+	plan, err := r.Plan(context.Background(), endpoint, desired, ActionCreate, portainer.Stack{})
+	if err != nil {
+		t.Fatalf("Plan returned error: %s", err)
+	}
+	if plan.Diff == "" {
+		t.Fatal("expected a non-empty diff")
+	}
+	if len(fc.created) != 0 {
+		t.Fatalf("expected Plan not to create, got %d", len(fc.created))
+	}
+}
+
+func TestDeleteIsNoopForUnknownStack(t *testing.T) {
+	fc := &fakeClient{}
+	r := New(fc)
+	endpoint := portainer.Endpoint{ID: 1, Name: "node-a"}
+
+	if err := r.Delete(context.Background(), endpoint, "missing-stack", map[string]portainer.Stack{}); err != nil {
+		t.Fatalf("Delete returned error: %s", err)
+	}
+	if len(fc.deleted) != 0 {
+		t.Fatalf("expected no delete, got %v", fc.deleted)
+	}
+}
@@ -0,0 +1,129 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+)
+
+// Stack is the fully-resolved desired state of a stack directory: its
+// type, compose/manifest content, and any manifest.yaml overrides.
+type Stack struct {
+	Type      portainer.StackType
+	Content   string
+	Namespace string
+	Env       []portainer.EnvVar
+	PullImage bool
+	Prune     bool
+}
+
+// Options controls how Load resolves a stack's content.
+type Options struct {
+	// NoSubstitute disables ${VAR} / ${VAR:-default} environment
+	// substitution, leaving the file content as-is.
+	NoSubstitute bool
+}
+
+// Load resolves change's stack directory into a Stack, detecting its
+// type from the files present (or a manifest.yaml override), reading
+// its compose/manifest content, and substituting environment variables
+// into it unless opts.NoSubstitute is set.
+func Load(change StackChange, opts Options) (Stack, error) {
+	m, err := loadManifest(filepath.Join(change.Path, "manifest.yaml"))
+	if err != nil {
+		return Stack{}, err
+	}
+
+	stackType := detectType(change.Path, m)
+
+	content, err := readContent(contentPath(change.Path, stackType), stackType)
+	if err != nil {
+		return Stack{}, err
+	}
+
+	if !opts.NoSubstitute {
+		env, err := loadEnv(change.Path)
+		if err != nil {
+			return Stack{}, err
+		}
+
+		content, err = substitute(content, env)
+		if err != nil {
+			return Stack{}, fmt.Errorf("failed to substitute variables for %s/%s: %w", change.Endpoint, change.Stack, err)
+		}
+	}
+
+	return Stack{
+		Type:      stackType,
+		Content:   content,
+		Namespace: m.namespace(),
+		Env:       m.envVars(),
+		PullImage: m.pullImage(),
+		Prune:     m.prune(),
+	}, nil
+}
+
+// detectType decides a stack's type: an explicit manifest.yaml override
+// wins, otherwise a docker-stack.yml means Swarm, a kubernetes/ folder
+// of manifests means Kubernetes, and anything else falls back to
+// standalone compose.
+func detectType(dir string, m *manifest) portainer.StackType {
+	if t, ok := m.stackType(); ok {
+		return t
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "docker-stack.yml")); err == nil {
+		return portainer.StackTypeSwarm
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, "kubernetes", "*.yaml")); len(matches) > 0 {
+		return portainer.StackTypeKubernetes
+	}
+
+	return portainer.StackTypeCompose
+}
+
+// contentPath returns where a stack's file content lives on disk for a
+// given type: a single file for compose and Swarm, a directory of
+// manifests for Kubernetes.
+func contentPath(dir string, stackType portainer.StackType) string {
+	switch stackType {
+	case portainer.StackTypeSwarm:
+		return filepath.Join(dir, "docker-stack.yml")
+	case portainer.StackTypeKubernetes:
+		return filepath.Join(dir, "kubernetes")
+	default:
+		return filepath.Join(dir, "docker-compose.yml")
+	}
+}
+
+func readContent(path string, stackType portainer.StackType) (string, error) {
+	if stackType != portainer.StackTypeKubernetes {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file '%s': %w", path, err)
+		}
+		return string(b), nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*.yaml"))
+	if err != nil {
+		return "", fmt.Errorf("failed to glob '%s': %w", path, err)
+	}
+	sort.Strings(matches)
+
+	docs := make([]string, 0, len(matches))
+	for _, match := range matches {
+		b, err := os.ReadFile(match)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file '%s': %w", match, err)
+		}
+		docs = append(docs, string(b))
+	}
+
+	return strings.Join(docs, "---\n"), nil
+}
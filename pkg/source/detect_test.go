@@ -0,0 +1,174 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory for '%s': %s", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write '%s': %s", path, err)
+	}
+}
+
+func TestDetectTypePrecedence(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  portainer.StackType
+	}{
+		{
+			name:  "compose by default",
+			files: map[string]string{"docker-compose.yml": "version: '3'\n"},
+			want:  portainer.StackTypeCompose,
+		},
+		{
+			name:  "docker-stack.yml means swarm",
+			files: map[string]string{"docker-stack.yml": "version: '3'\n"},
+			want:  portainer.StackTypeSwarm,
+		},
+		{
+			name:  "kubernetes folder means kubernetes",
+			files: map[string]string{"kubernetes/deployment.yaml": "kind: Deployment\n"},
+			want:  portainer.StackTypeKubernetes,
+		},
+		{
+			name: "manifest override wins over docker-stack.yml",
+			files: map[string]string{
+				"manifest.yaml":    "type: compose\n",
+				"docker-stack.yml": "version: '3'\n",
+				// not read by detectType; Load would read it for compose
+				"docker-compose.yml": "version: '3'\n",
+			},
+			want: portainer.StackTypeCompose,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(dir, name), content)
+			}
+
+			m, err := loadManifest(filepath.Join(dir, "manifest.yaml"))
+			if err != nil {
+				t.Fatalf("loadManifest returned error: %s", err)
+			}
+
+			got := detectType(dir, m)
+			if got != tt.want {
+				t.Fatalf("detectType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentPath(t *testing.T) {
+	tests := []struct {
+		stackType portainer.StackType
+		want      string
+	}{
+		{portainer.StackTypeCompose, "docker-compose.yml"},
+		{portainer.StackTypeSwarm, "docker-stack.yml"},
+		{portainer.StackTypeKubernetes, "kubernetes"},
+	}
+
+	for _, tt := range tests {
+		got := contentPath("/stacks/node/stack", tt.stackType)
+		want := filepath.Join("/stacks/node/stack", tt.want)
+		if got != want {
+			t.Errorf("contentPath(%v) = %q, want %q", tt.stackType, got, want)
+		}
+	}
+}
+
+func TestReadContentJoinsKubernetesManifests(t *testing.T) {
+	dir := t.TempDir()
+	kubeDir := filepath.Join(dir, "kubernetes")
+	writeFile(t, filepath.Join(kubeDir, "a-deployment.yaml"), "kind: Deployment\n")
+	writeFile(t, filepath.Join(kubeDir, "b-service.yaml"), "kind: Service\n")
+
+	content, err := readContent(kubeDir, portainer.StackTypeKubernetes)
+	if err != nil {
+		t.Fatalf("readContent returned error: %s", err)
+	}
+
+	want := "kind: Deployment\n---\nkind: Service\n"
+	if content != want {
+		t.Fatalf("readContent() = %q, want %q", content, want)
+	}
+}
+
+func TestReadContentSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker-compose.yml")
+	writeFile(t, path, "version: '3'\n")
+
+	content, err := readContent(path, portainer.StackTypeCompose)
+	if err != nil {
+		t.Fatalf("readContent returned error: %s", err)
+	}
+	if content != "version: '3'\n" {
+		t.Fatalf("readContent() = %q", content)
+	}
+}
+
+func TestManifestDefaults(t *testing.T) {
+	var m *manifest
+
+	if !m.pullImage() {
+		t.Error("expected pullImage to default to true for a nil manifest")
+	}
+	if m.prune() {
+		t.Error("expected prune to default to false for a nil manifest")
+	}
+	if m.namespace() != "" {
+		t.Error("expected namespace to default to empty for a nil manifest")
+	}
+	if env := m.envVars(); env != nil {
+		t.Errorf("expected envVars to default to nil for a nil manifest, got %v", env)
+	}
+	if _, ok := m.stackType(); ok {
+		t.Error("expected stackType to report false for a nil manifest")
+	}
+}
+
+func TestManifestPullImageExplicitFalse(t *testing.T) {
+	no := false
+	m := &manifest{PullImage: &no}
+
+	if m.pullImage() {
+		t.Error("expected pullImage to honor an explicit false")
+	}
+}
+
+func TestManifestStackType(t *testing.T) {
+	tests := []struct {
+		yamlType string
+		want     portainer.StackType
+		wantOK   bool
+	}{
+		{"swarm", portainer.StackTypeSwarm, true},
+		{"compose", portainer.StackTypeCompose, true},
+		{"standalone", portainer.StackTypeCompose, true},
+		{"kubernetes", portainer.StackTypeKubernetes, true},
+		{"nonsense", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		m := &manifest{Type: tt.yamlType}
+		got, ok := m.stackType()
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("stackType() for %q = (%v, %v), want (%v, %v)", tt.yamlType, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/drone/envsubst"
+)
+
+// bareVarPattern matches a variable reference with no envsubst default,
+// e.g. ${PORTAINER_TAG}. References with a default, e.g.
+// ${PORTAINER_TAG:-latest}, are left to resolve on their own.
+var bareVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// substitute resolves ${VAR} and ${VAR:-default} references in content
+// using env, failing fast if a reference has no default and no value in
+// env.
+func substitute(content string, env map[string]string) (string, error) {
+	for _, m := range bareVarPattern.FindAllStringSubmatch(content, -1) {
+		name := m[1]
+		if _, ok := env[name]; !ok {
+			return "", fmt.Errorf("unresolved variable ${%s} (set it in a .env file or use ${%s:-default})", name, name)
+		}
+	}
+
+	out, err := envsubst.Eval(content, func(name string) string {
+		return env[name]
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to substitute variables: %w", err)
+	}
+
+	return out, nil
+}
+
+// loadEnv builds the variable lookup for a stack directory, merging the
+// process environment with stacks/.env, stacks/<node>/.env, and
+// stacks/<node>/<stack>/.env, each overriding the last.
+func loadEnv(dir string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	node := filepath.Dir(dir)
+	root := filepath.Dir(node)
+
+	for _, path := range []string{
+		filepath.Join(root, ".env"),
+		filepath.Join(node, ".env"),
+		filepath.Join(dir, ".env"),
+	} {
+		if err := mergeEnvFile(env, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+func mergeEnvFile(env map[string]string, path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read env file '%s': %w", path, err)
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		env[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package source
+
+import "testing"
+
+func TestSubstituteResolvesKnownVars(t *testing.T) {
+	env := map[string]string{"TAG": "1.2.3"}
+
+	out, err := substitute("image: app:${TAG}\n", env)
+	if err != nil {
+		t.Fatalf("substitute returned error: %s", err)
+	}
+	if out != "image: app:1.2.3\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSubstituteAllowsDefaultForMissingVar(t *testing.T) {
+	out, err := substitute("image: app:${TAG:-latest}\n", map[string]string{})
+	if err != nil {
+		t.Fatalf("substitute returned error: %s", err)
+	}
+	if out != "image: app:latest\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestSubstituteFailsFastOnUnresolvedVar(t *testing.T) {
+	_, err := substitute("image: app:${TAG}\n", map[string]string{})
+	if err == nil {
+		t.Fatal("expected an error for unresolved ${TAG}")
+	}
+}
@@ -0,0 +1,93 @@
+package source
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/0x23me/portainer-xtc/pkg/portainer"
+)
+
+// manifest is the optional per-stack manifest.yaml. It lets operators
+// override type detection and pass deploy options Portainer's Swarm and
+// Kubernetes update endpoints accept.
+type manifest struct {
+	Type      string            `yaml:"type"`
+	Namespace string            `yaml:"namespace"`
+	Env       map[string]string `yaml:"env"`
+	PullImage *bool             `yaml:"pullImage"`
+	Prune     bool              `yaml:"prune"`
+}
+
+// loadManifest reads path, returning a nil manifest (and no error) if
+// it doesn't exist.
+func loadManifest(path string) (*manifest, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest '%s': %w", path, err)
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest '%s': %w", path, err)
+	}
+
+	return &m, nil
+}
+
+// stackType resolves the manifest's type field to a portainer.StackType,
+// reporting false if the manifest is absent or doesn't set a type.
+func (m *manifest) stackType() (portainer.StackType, bool) {
+	if m == nil {
+		return 0, false
+	}
+
+	switch m.Type {
+	case "swarm":
+		return portainer.StackTypeSwarm, true
+	case "compose", "standalone":
+		return portainer.StackTypeCompose, true
+	case "kubernetes":
+		return portainer.StackTypeKubernetes, true
+	default:
+		return 0, false
+	}
+}
+
+func (m *manifest) pullImage() bool {
+	if m == nil || m.PullImage == nil {
+		return true
+	}
+	return *m.PullImage
+}
+
+func (m *manifest) prune() bool {
+	if m == nil {
+		return false
+	}
+	return m.Prune
+}
+
+func (m *manifest) namespace() string {
+	if m == nil {
+		return ""
+	}
+	return m.Namespace
+}
+
+func (m *manifest) envVars() []portainer.EnvVar {
+	if m == nil || len(m.Env) == 0 {
+		return nil
+	}
+
+	env := make([]portainer.EnvVar, 0, len(m.Env))
+	for name, value := range m.Env {
+		env = append(env, portainer.EnvVar{Name: name, Value: value})
+	}
+
+	return env
+}
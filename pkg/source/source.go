@@ -0,0 +1,55 @@
+// Package source locates stacks on disk, either via a one-off scan or a
+// filesystem watch, and reports them as StackChange events.
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StackChange identifies a single stack directory that should be
+// reconciled, as discovered either from an initial directory scan or
+// from a filesystem watch event.
+type StackChange struct {
+	Endpoint string
+	Stack    string
+	Path     string
+}
+
+// Scan walks dir, which is expected to contain one subdirectory per
+// node/endpoint, each containing one subdirectory per stack, and
+// returns a StackChange for every stack directory found.
+func Scan(dir string) ([]StackChange, error) {
+	nodes, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory '%s': %w", dir, err)
+	}
+
+	var changes []StackChange
+	for _, node := range nodes {
+		if !node.IsDir() {
+			continue
+		}
+
+		nodePath := filepath.Join(dir, node.Name())
+		stacks, err := os.ReadDir(nodePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory '%s': %w", nodePath, err)
+		}
+
+		for _, stack := range stacks {
+			if !stack.IsDir() {
+				continue
+			}
+
+			changes = append(changes, StackChange{
+				Endpoint: node.Name(),
+				Stack:    stack.Name(),
+				Path:     filepath.Join(nodePath, stack.Name()),
+			})
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,95 @@
+package source
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/rjeczalik/notify"
+)
+
+// Watcher watches a stack files directory tree and turns raw filesystem
+// events into StackChange values.
+type Watcher struct {
+	dir    string
+	events chan notify.EventInfo
+}
+
+// Watch starts watching dir, and everything below it, for filesystem
+// events. Call Stop when done to release the underlying watch.
+func Watch(dir string) (*Watcher, error) {
+	events := make(chan notify.EventInfo, 1)
+	if err := notify.Watch(dir+"...", events, notify.All); err != nil {
+		return nil, err
+	}
+
+	return &Watcher{dir: dir, events: events}, nil
+}
+
+// Stop releases the underlying filesystem watch.
+func (w *Watcher) Stop() {
+	notify.Stop(w.events)
+}
+
+// Changes returns a channel of StackChange derived from raw filesystem
+// events. Events whose path is shallower than <dir>/<node>/<stack>/...
+// can't be mapped to a single stack and are dropped, except for a
+// shared stacks/.env or stacks/<node>/.env overlay, which expands into
+// a StackChange for every stack directory in its scope, since an edit
+// there changes every stack that loads it.
+func (w *Watcher) Changes() <-chan StackChange {
+	out := make(chan StackChange, 1)
+
+	go func() {
+		defer close(out)
+		for ei := range w.events {
+			rel, err := filepath.Rel(w.dir, ei.Path())
+			if err != nil {
+				continue
+			}
+
+			parts := strings.Split(filepath.ToSlash(rel), "/")
+
+			if scope, ok := envScope(parts); ok {
+				changes, err := Scan(w.dir)
+				if err != nil {
+					continue
+				}
+				for _, c := range changes {
+					if scope != "" && c.Endpoint != scope {
+						continue
+					}
+					out <- c
+				}
+				continue
+			}
+
+			if len(parts) < 3 {
+				continue
+			}
+
+			parts = parts[:3]
+			out <- StackChange{
+				Endpoint: parts[0],
+				Stack:    parts[1],
+				Path:     filepath.Join(w.dir, parts[0], parts[1]),
+			}
+		}
+	}()
+
+	return out
+}
+
+// envScope reports whether parts is the relative path of a shared .env
+// overlay: "" (and ok) for the root stacks/.env, in scope for every
+// stack, or a node name for stacks/<node>/.env, in scope for every
+// stack under that node.
+func envScope(parts []string) (scope string, ok bool) {
+	switch {
+	case len(parts) == 1 && parts[0] == ".env":
+		return "", true
+	case len(parts) == 2 && parts[1] == ".env":
+		return parts[0], true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,27 @@
+package source
+
+import "testing"
+
+func TestEnvScope(t *testing.T) {
+	tests := []struct {
+		parts     []string
+		wantScope string
+		wantOK    bool
+	}{
+		{parts: []string{".env"}, wantScope: "", wantOK: true},
+		{parts: []string{"nodeA", ".env"}, wantScope: "nodeA", wantOK: true},
+		{parts: []string{"nodeA", "stackA", ".env"}, wantOK: false},
+		{parts: []string{"nodeA", "stackA", "docker-compose.yml"}, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := envScope(tt.parts)
+		if ok != tt.wantOK {
+			t.Errorf("envScope(%v) ok = %v, want %v", tt.parts, ok, tt.wantOK)
+			continue
+		}
+		if ok && scope != tt.wantScope {
+			t.Errorf("envScope(%v) scope = %q, want %q", tt.parts, scope, tt.wantScope)
+		}
+	}
+}